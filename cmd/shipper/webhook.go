@@ -0,0 +1,71 @@
+package main
+
+import (
+	"time"
+
+	client "github.com/prometheus/client_golang/prometheus"
+	admission "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+	clientset "github.com/bookingcom/shipper/pkg/client/clientset/versioned"
+	informers "github.com/bookingcom/shipper/pkg/client/informers/externalversions"
+	shipperprometheus "github.com/bookingcom/shipper/pkg/metrics/prometheus"
+	"github.com/bookingcom/shipper/pkg/webhook"
+)
+
+// startWebhook wires up and runs the shipper-webhook admission webhook: it
+// registers the request-metrics collectors and the Application/Release
+// mutators, then hands off to webhook.Webhook.Run. The clientset, informer
+// factory and webhook health metric are taken from the caller.
+func startWebhook(
+	bindAddr, bindPort, tlsPrivateKeyFile, tlsCertFile string,
+	shipperClientset clientset.Interface,
+	shipperInformerFactory informers.SharedInformerFactory,
+	webhookMetric shipperprometheus.WebhookMetric,
+	heartbeatPeriod time.Duration,
+	defaultRolloutBlocks []string,
+	defaultChartRepoURL string,
+	defaultChartValues *shipper.ChartValues,
+	stopCh <-chan struct{},
+) {
+	webhookRequestMetric := shipperprometheus.NewWebhookRequestMetrics()
+	client.MustRegister(webhookRequestMetric.Collectors()...)
+
+	w := webhook.NewWebhook(
+		bindAddr, bindPort, tlsPrivateKeyFile, tlsCertFile,
+		shipperClientset,
+		shipperInformerFactory,
+		webhookMetric,
+		webhookRequestMetric,
+		heartbeatPeriod,
+	)
+
+	ownerLabelMutator := webhook.NewOwnerLabelMutator()
+	rolloutBlockOverrideMutator := webhook.NewRolloutBlockOverrideMutator(defaultRolloutBlocks)
+	applicationDefaultsMutator := webhook.NewApplicationDefaultsMutator(defaultChartRepoURL, defaultChartValues)
+
+	w.RegisterMutator("Application", chainMutators(applicationDefaultsMutator, rolloutBlockOverrideMutator, ownerLabelMutator),
+		func() runtime.Object { return &shipper.Application{} })
+	w.RegisterMutator("Release", chainMutators(rolloutBlockOverrideMutator, ownerLabelMutator),
+		func() runtime.Object { return &shipper.Release{} })
+
+	w.Run(stopCh)
+}
+
+// chainMutators runs each Mutator in order, threading the previous one's
+// output into the next, so a kind that needs more than one mutation (e.g.
+// Applications get both their chart defaults and their owner/team labels)
+// can still register under a single registry entry.
+func chainMutators(mutators ...webhook.Mutator) webhook.Mutator {
+	return webhook.MutatorFunc(func(request *admission.AdmissionRequest, obj runtime.Object) (runtime.Object, error) {
+		var err error
+		for _, mutator := range mutators {
+			obj, err = mutator.Mutate(request, obj)
+			if err != nil {
+				return obj, err
+			}
+		}
+		return obj, nil
+	})
+}
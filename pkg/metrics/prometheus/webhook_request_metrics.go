@@ -0,0 +1,128 @@
+package prometheus
+
+import (
+	"strconv"
+	"time"
+
+	client "github.com/prometheus/client_golang/prometheus"
+)
+
+// otherResourceKind buckets any kind the webhook doesn't explicitly
+// recognize, so a malformed or unexpected request can't blow up label
+// cardinality.
+const otherResourceKind = "other"
+
+var knownResourceKinds = map[string]bool{
+	"Application":        true,
+	"Release":            true,
+	"Cluster":            true,
+	"InstallationTarget": true,
+	"CapacityTarget":     true,
+	"TrafficTarget":      true,
+	"RolloutBlock":       true,
+}
+
+func sanitizeResourceKind(kind string) string {
+	if knownResourceKinds[kind] {
+		return kind
+	}
+	return otherResourceKind
+}
+
+// otherContentType buckets any Content-Type the webhook doesn't explicitly
+// recognize. Content-Type is client-controlled, so feeding it to a
+// Prometheus label unsanitized would let a client blow up cardinality.
+const otherContentType = "other"
+
+var knownContentTypes = map[string]bool{
+	"application/json": true,
+	"":                 true,
+}
+
+func sanitizeContentType(contentType string) string {
+	if knownContentTypes[contentType] {
+		return contentType
+	}
+	return otherContentType
+}
+
+// WebhookRequestMetrics instruments per-admission-request outcomes:
+// latency, decision counts, in-flight load, and decode failures.
+type WebhookRequestMetrics interface {
+	ObserveRequestLatency(resourceKind, operation string, allowed bool, duration time.Duration)
+	ObserveRequestTotal(resourceKind, operation string, allowed bool, reason string)
+	IncInFlight(resourceKind string)
+	DecInFlight(resourceKind string)
+	ObserveDecodeError(contentType string)
+	Collectors() []client.Collector
+}
+
+type webhookRequestMetrics struct {
+	latency      *client.HistogramVec
+	requestTotal *client.CounterVec
+	inFlight     *client.GaugeVec
+	decodeErrors *client.CounterVec
+}
+
+// NewWebhookRequestMetrics builds the Prometheus collectors backing
+// WebhookRequestMetrics. Callers are expected to register the result with
+// Collectors() alongside the webhook's other collectors.
+func NewWebhookRequestMetrics() WebhookRequestMetrics {
+	return &webhookRequestMetrics{
+		latency: client.NewHistogramVec(client.HistogramOpts{
+			Namespace: "shipper",
+			Subsystem: "webhook",
+			Name:      "admission_request_duration_seconds",
+			Help:      "Latency of admission requests handled by the webhook.",
+			Buckets:   client.DefBuckets,
+		}, []string{"resource_kind", "operation", "allowed"}),
+
+		requestTotal: client.NewCounterVec(client.CounterOpts{
+			Namespace: "shipper",
+			Subsystem: "webhook",
+			Name:      "admission_requests_total",
+			Help:      "Count of admission decisions made by the webhook.",
+		}, []string{"resource_kind", "operation", "allowed", "reason"}),
+
+		inFlight: client.NewGaugeVec(client.GaugeOpts{
+			Namespace: "shipper",
+			Subsystem: "webhook",
+			Name:      "admission_requests_in_flight",
+			Help:      "Number of admission requests currently being handled.",
+		}, []string{"resource_kind"}),
+
+		decodeErrors: client.NewCounterVec(client.CounterOpts{
+			Namespace: "shipper",
+			Subsystem: "webhook",
+			Name:      "admission_decode_errors_total",
+			Help:      "Count of admission requests that failed to decode or used an unsupported content-type.",
+		}, []string{"content_type"}),
+	}
+}
+
+func (m *webhookRequestMetrics) ObserveRequestLatency(resourceKind, operation string, allowed bool, duration time.Duration) {
+	m.latency.WithLabelValues(sanitizeResourceKind(resourceKind), operation, strconv.FormatBool(allowed)).Observe(duration.Seconds())
+}
+
+func (m *webhookRequestMetrics) ObserveRequestTotal(resourceKind, operation string, allowed bool, reason string) {
+	m.requestTotal.WithLabelValues(sanitizeResourceKind(resourceKind), operation, strconv.FormatBool(allowed), reason).Inc()
+}
+
+func (m *webhookRequestMetrics) IncInFlight(resourceKind string) {
+	m.inFlight.WithLabelValues(sanitizeResourceKind(resourceKind)).Inc()
+}
+
+func (m *webhookRequestMetrics) DecInFlight(resourceKind string) {
+	m.inFlight.WithLabelValues(sanitizeResourceKind(resourceKind)).Dec()
+}
+
+func (m *webhookRequestMetrics) ObserveDecodeError(contentType string) {
+	m.decodeErrors.WithLabelValues(sanitizeContentType(contentType)).Inc()
+}
+
+// Collectors returns the underlying Prometheus collectors so callers (e.g.
+// cmd/shipper, where the other webhook collectors are registered) can
+// register these too.
+func (m *webhookRequestMetrics) Collectors() []client.Collector {
+	return []client.Collector{m.latency, m.requestTotal, m.inFlight, m.decodeErrors}
+}
@@ -0,0 +1,48 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSanitizeResourceKind(t *testing.T) {
+	cases := map[string]string{
+		"Application": "Application",
+		"Release":     "Release",
+		"Secret":      otherResourceKind,
+		"":            otherResourceKind,
+	}
+
+	for kind, want := range cases {
+		if got := sanitizeResourceKind(kind); got != want {
+			t.Errorf("sanitizeResourceKind(%q) = %q, want %q", kind, got, want)
+		}
+	}
+}
+
+func TestSanitizeContentType(t *testing.T) {
+	cases := map[string]string{
+		"application/json": "application/json",
+		"":                 "",
+		"text/plain":       otherContentType,
+		"application/xml; charset=attacker-controlled-garbage": otherContentType,
+	}
+
+	for contentType, want := range cases {
+		if got := sanitizeContentType(contentType); got != want {
+			t.Errorf("sanitizeContentType(%q) = %q, want %q", contentType, got, want)
+		}
+	}
+}
+
+func TestWebhookRequestMetricsObserveDecodeErrorCapsCardinality(t *testing.T) {
+	metrics := NewWebhookRequestMetrics().(*webhookRequestMetrics)
+
+	metrics.ObserveDecodeError("whatever-a-client-feels-like-sending")
+
+	got := testutil.ToFloat64(metrics.decodeErrors.WithLabelValues(otherContentType))
+	if got != 1 {
+		t.Errorf("expected one observation bucketed under %q, got %v", otherContentType, got)
+	}
+}
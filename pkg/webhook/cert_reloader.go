@@ -0,0 +1,148 @@
+package webhook
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/klog"
+)
+
+// reloadSafetyNetPeriod is how often CertReloader re-parses the certificate
+// from disk even if no fsnotify event fired, as a safety net against missed
+// events.
+const reloadSafetyNetPeriod = 5 * time.Minute
+
+// CertReloader keeps a TLS certificate/key pair in sync with the files on
+// disk, so rotating the secret backing a projected volume doesn't require
+// restarting the webhook pod.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	onReload func(certificate *x509.Certificate)
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+// NewCertReloader loads the initial certificate from certFile/keyFile and
+// starts watching them for changes. onReload, if non-nil, is called with the
+// parsed leaf certificate every time a new pair is loaded, so callers can
+// keep things like certificate-expiration metrics up to date.
+func NewCertReloader(certFile, keyFile string, onReload func(*x509.Certificate)) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		onReload: onReload,
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dir := range watchedDirs(certFile, keyFile) {
+		if err := watcher.Add(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	go r.watch(watcher)
+	go r.periodicReload()
+
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate, so http.Server picks
+// up the latest loaded certificate on the next handshake.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reload re-reads the certificate and key from disk. It's exported so tests
+// can force a reload deterministically instead of waiting on fsnotify.
+func (r *CertReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.mu.Unlock()
+
+	if r.onReload != nil {
+		if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil {
+			r.onReload(leaf)
+		} else {
+			klog.Errorf("fail to parse reloaded TLS certificate %v", err)
+		}
+	}
+
+	klog.V(2).Infof("reloaded TLS certificate from %s", r.certFile)
+
+	return nil
+}
+
+func (r *CertReloader) watch(watcher *fsnotify.Watcher) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			// Kubernetes projects secret volumes via an atomic symlink swap
+			// on the parent directory, which surfaces as CREATE/RENAME
+			// rather than WRITE on the file itself.
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+
+			if err := r.Reload(); err != nil {
+				klog.Errorf("fail to reload TLS certificate after %s: %v", event, err)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			klog.Errorf("fsnotify error watching TLS certificate: %v", err)
+		}
+	}
+}
+
+func (r *CertReloader) periodicReload() {
+	ticker := time.NewTicker(reloadSafetyNetPeriod)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := r.Reload(); err != nil {
+			klog.Errorf("fail to periodically reload TLS certificate: %v", err)
+		}
+	}
+}
+
+// watchedDirs returns the deduplicated parent directories of the given
+// files, since fsnotify watches directories rather than individual inodes.
+func watchedDirs(files ...string) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, f := range files {
+		dir := filepath.Dir(f)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
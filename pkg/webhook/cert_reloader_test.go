@@ -0,0 +1,110 @@
+package webhook
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCertReloaderLoadsInitialCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, "localhost")
+
+	reloader, err := NewCertReloader(certFile, keyFile, nil)
+	if err != nil {
+		t.Fatalf("NewCertReloader returned an error: %v", err)
+	}
+
+	cert, err := reloader.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate returned an error: %v", err)
+	}
+	if cert == nil {
+		t.Fatal("expected a non-nil certificate")
+	}
+}
+
+func TestCertReloaderReloadPicksUpNewCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, "localhost")
+
+	var reloaded *x509.Certificate
+	reloader, err := NewCertReloader(certFile, keyFile, func(leaf *x509.Certificate) {
+		reloaded = leaf
+	})
+	if err != nil {
+		t.Fatalf("NewCertReloader returned an error: %v", err)
+	}
+
+	writeTestCertificate(t, dir, "updated.localhost")
+
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload returned an error: %v", err)
+	}
+
+	if reloaded == nil || reloaded.Subject.CommonName != "updated.localhost" {
+		t.Fatalf("expected onReload to observe the new certificate, got %+v", reloaded)
+	}
+}
+
+func writeTestCertificate(t *testing.T, dir, commonName string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate test key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create test certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal test key: %v", err)
+	}
+
+	certFile = filepath.Join(dir, "tls.crt")
+	keyFile = filepath.Join(dir, "tls.key")
+
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+
+	// Sanity check: tls.LoadX509KeyPair is what CertReloader relies on.
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		t.Fatalf("test fixture produced an invalid key pair: %v", err)
+	}
+
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create %s: %v", path, err)
+	}
+	defer f.Close()
+
+	if err := pem.Encode(f, &pem.Block{Type: blockType, Bytes: der}); err != nil {
+		t.Fatalf("failed to write PEM block to %s: %v", path, err)
+	}
+}
@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	admission "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+)
+
+// appLabel is the label Shipper stamps on a Release to tie it back to its
+// owning Application.
+const appLabel = "shipper.io/app"
+
+// validateDeleteHandlerFunc runs kind-specific policy on DELETE requests.
+// request.Object is empty on delete -- the apiserver populates OldObject
+// instead -- so this is a separate path from validateHandlerFunc's
+// create/update validation. It has no side effects, so a dry-run delete
+// gets exactly the same verdict as a real one.
+func (c *Webhook) validateDeleteHandlerFunc(request *admission.AdmissionRequest) *admission.AdmissionResponse {
+	var err error
+
+	switch request.Kind.Kind {
+	case "RolloutBlock":
+		var rolloutBlock shipper.RolloutBlock
+		if err = json.Unmarshal(request.OldObject.Raw, &rolloutBlock); err == nil {
+			err = c.validateRolloutBlockDeletion(rolloutBlock)
+		}
+	case "Application":
+		var application shipper.Application
+		if err = json.Unmarshal(request.OldObject.Raw, &application); err == nil {
+			err = c.validateApplicationDeletion(application)
+		}
+	case "InstallationTarget", "CapacityTarget", "TrafficTarget":
+		// These are derived, disposable per-cluster state recreated on the
+		// next rollout; there's never a reason to block their deletion.
+		return &admission.AdmissionResponse{Allowed: true}
+	}
+
+	if err != nil {
+		return &admission.AdmissionResponse{
+			Result: statusForError(err),
+		}
+	}
+
+	return &admission.AdmissionResponse{
+		Allowed: true,
+	}
+}
+
+// validateRolloutBlockDeletion rejects deleting a RolloutBlock that's still
+// referenced by an Application or Release's override annotation, so the
+// override doesn't silently start pointing at nothing.
+func (c *Webhook) validateRolloutBlockDeletion(rolloutBlock shipper.RolloutBlock) error {
+	blockRef := rolloutBlock.Namespace + "/" + rolloutBlock.Name
+
+	applications, err := c.applicationsLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, application := range applications {
+		if hasRolloutBlockOverride(application.Annotations, blockRef) {
+			return &ValidationError{
+				Field:                 "metadata.name",
+				Detail:                fmt.Sprintf("RolloutBlock %s is still referenced by Application %s/%s", blockRef, application.Namespace, application.Name),
+				BlockingRolloutBlocks: []string{blockRef},
+			}
+		}
+	}
+
+	releases, err := c.releasesLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, release := range releases {
+		if hasRolloutBlockOverride(release.Annotations, blockRef) {
+			return &ValidationError{
+				Field:                 "metadata.name",
+				Detail:                fmt.Sprintf("RolloutBlock %s is still referenced by Release %s/%s", blockRef, release.Namespace, release.Name),
+				BlockingRolloutBlocks: []string{blockRef},
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateApplicationDeletion rejects deleting an Application while it has
+// more than one Release -- Shipper's signal that a rollout (incumbent plus
+// contender) is still in flight -- unless the Application carries a
+// rollout-block override.
+func (c *Webhook) validateApplicationDeletion(application shipper.Application) error {
+	if _, ok := application.Annotations[shipper.RolloutBlocksOverrideAnnotation]; ok {
+		return nil
+	}
+
+	releases, err := c.releasesLister.Releases(application.Namespace).List(labels.Set{appLabel: application.Name}.AsSelector())
+	if err != nil {
+		return err
+	}
+
+	if len(releases) > 1 {
+		return &ValidationError{
+			Field:  "metadata.name",
+			Detail: fmt.Sprintf("Application %s/%s has a rollout in progress (%d releases); add the %s annotation to override", application.Namespace, application.Name, len(releases), shipper.RolloutBlocksOverrideAnnotation),
+		}
+	}
+
+	return nil
+}
+
+func hasRolloutBlockOverride(annotations map[string]string, blockRef string) bool {
+	for _, ref := range strings.Split(annotations[shipper.RolloutBlocksOverrideAnnotation], ",") {
+		if strings.TrimSpace(ref) == blockRef {
+			return true
+		}
+	}
+	return false
+}
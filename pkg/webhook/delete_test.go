@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"testing"
+
+	admission "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+)
+
+func TestHasRolloutBlockOverride(t *testing.T) {
+	annotations := map[string]string{
+		shipper.RolloutBlocksOverrideAnnotation: "shipper/a, shipper/b",
+	}
+
+	if !hasRolloutBlockOverride(annotations, "shipper/a") {
+		t.Error("expected shipper/a to be found in the override annotation")
+	}
+	if !hasRolloutBlockOverride(annotations, "shipper/b") {
+		t.Error("expected shipper/b to be found in the override annotation")
+	}
+	if hasRolloutBlockOverride(annotations, "shipper/c") {
+		t.Error("expected shipper/c not to be found in the override annotation")
+	}
+}
+
+func TestHasRolloutBlockOverrideNilAnnotations(t *testing.T) {
+	if hasRolloutBlockOverride(nil, "shipper/a") {
+		t.Error("expected no override to be found when annotations are nil")
+	}
+}
+
+func TestValidateDeleteHandlerFuncAllowsDerivedTargetsUnconditionally(t *testing.T) {
+	// InstallationTarget/CapacityTarget/TrafficTarget deletion never
+	// consults a lister, so a zero-value Webhook is enough to prove the
+	// short-circuit behaves the same for a dry-run delete as a real one.
+	c := &Webhook{}
+
+	for _, kind := range []string{"InstallationTarget", "CapacityTarget", "TrafficTarget"} {
+		for _, dryRun := range []bool{false, true} {
+			request := &admission.AdmissionRequest{
+				Kind:   metav1.GroupVersionKind{Group: "shipper.booking.com", Version: "v1alpha1", Kind: kind},
+				DryRun: &dryRun,
+			}
+
+			response := c.validateDeleteHandlerFunc(request)
+			if !response.Allowed {
+				t.Errorf("kind=%s dryRun=%v: expected the delete to be allowed", kind, dryRun)
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+)
+
+// rolloutBlockOverrideField is the JSON path kubectl should point users at
+// when a rollout-block override annotation is missing or invalid.
+var rolloutBlockOverrideField = fmt.Sprintf("metadata.annotations[%q]", shipper.RolloutBlocksOverrideAnnotation)
+
+// ValidationError carries enough structure about a rejected admission
+// request for adaptHandler to build a Result with a field path kubectl can
+// show, instead of a bare "denied the request" message.
+type ValidationError struct {
+	// Field is the JSON path of the offending field, e.g.
+	// "spec.environment" or the rollout-block override annotation path.
+	Field string
+
+	Reason metav1.StatusReason
+	Detail string
+
+	// BlockingRolloutBlocks lists the "namespace/name" rollout blocks that
+	// are preventing the change, when the rejection is block-related.
+	BlockingRolloutBlocks []string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Detail
+}
+
+// rolloutBlockValidationError wraps an error returned by pkg/util/rolloutblock
+// into a ValidationError carrying the offending block names, so kubectl
+// users see exactly which rollout block is missing from the override
+// annotation instead of an opaque message. blocking is the set of
+// "namespace/name" rollout blocks actually responsible for the failure --
+// computed by the caller, since a ValidateAnnotations failure and a
+// ValidateBlocks failure are offended by different blocks.
+func rolloutBlockValidationError(err error, blocking []string) error {
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		return err
+	}
+
+	return &ValidationError{
+		Field:                 rolloutBlockOverrideField,
+		Detail:                err.Error(),
+		BlockingRolloutBlocks: blocking,
+	}
+}
+
+// rolloutBlocksMissingOverride returns the "namespace/name" entries in
+// existingBlocks that aren't covered by overrides, i.e. the rollout blocks
+// actually blocking the request because the caller hasn't (yet) overridden
+// them.
+func rolloutBlocksMissingOverride(existingBlocks, overrides []string) []string {
+	overridden := make(map[string]bool, len(overrides))
+	for _, o := range overrides {
+		overridden[o] = true
+	}
+
+	var missing []string
+	for _, block := range existingBlocks {
+		if !overridden[block] {
+			missing = append(missing, block)
+		}
+	}
+
+	return missing
+}
+
+// invalidRolloutBlockOverrides returns the "namespace/name" entries in
+// overrides that don't correspond to any existingBlock, i.e. stale or
+// mistyped references in the override annotation.
+func invalidRolloutBlockOverrides(existingBlocks, overrides []string) []string {
+	existing := make(map[string]bool, len(existingBlocks))
+	for _, b := range existingBlocks {
+		existing[b] = true
+	}
+
+	var invalid []string
+	for _, override := range overrides {
+		if !existing[override] {
+			invalid = append(invalid, override)
+		}
+	}
+
+	return invalid
+}
+
+// statusForError builds the metav1.Status an AdmissionResponse.Result
+// should carry for err. *ValidationError is translated into a
+// StatusReasonInvalid response with one Cause per offending field; any
+// other error falls back to a plain failure message, same as before this
+// type existed.
+func statusForError(err error) *metav1.Status {
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		return &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: err.Error(),
+		}
+	}
+
+	status := &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Message: verr.Detail,
+		Reason:  metav1.StatusReasonInvalid,
+		Code:    422,
+	}
+
+	if verr.Reason != "" {
+		status.Reason = verr.Reason
+	}
+
+	var causes []metav1.StatusCause
+	if verr.Field != "" {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: verr.Detail,
+			Field:   verr.Field,
+		})
+	}
+
+	for _, block := range verr.BlockingRolloutBlocks {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: fmt.Sprintf("blocked by rollout block %q", block),
+			Field:   rolloutBlockOverrideField,
+		})
+	}
+
+	if len(causes) > 0 {
+		status.Details = &metav1.StatusDetails{Causes: causes}
+	}
+
+	return status
+}
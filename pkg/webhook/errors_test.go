@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRolloutBlocksMissingOverride(t *testing.T) {
+	existing := []string{"shipper/a", "shipper/b"}
+	overrides := []string{"shipper/a"}
+
+	got := rolloutBlocksMissingOverride(existing, overrides)
+	want := []string{"shipper/b"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rolloutBlocksMissingOverride() = %v, want %v", got, want)
+	}
+}
+
+func TestInvalidRolloutBlockOverrides(t *testing.T) {
+	existing := []string{"shipper/a"}
+	overrides := []string{"shipper/a", "shipper/stale"}
+
+	got := invalidRolloutBlockOverrides(existing, overrides)
+	want := []string{"shipper/stale"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("invalidRolloutBlockOverrides() = %v, want %v", got, want)
+	}
+}
+
+func TestRolloutBlockValidationErrorUsesTheRealAnnotation(t *testing.T) {
+	err := rolloutBlockValidationError(errors.New("blocked"), []string{"shipper/b"})
+
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a *ValidationError, got %T", err)
+	}
+
+	wantField := `metadata.annotations["shipper.booking.com/rollout-block.override"]`
+	if verr.Field != wantField {
+		t.Errorf("Field = %q, want %q", verr.Field, wantField)
+	}
+	if !reflect.DeepEqual(verr.BlockingRolloutBlocks, []string{"shipper/b"}) {
+		t.Errorf("BlockingRolloutBlocks = %v, want %v", verr.BlockingRolloutBlocks, []string{"shipper/b"})
+	}
+}
+
+func TestRolloutBlockValidationErrorPassesThroughExistingValidationError(t *testing.T) {
+	original := &ValidationError{Field: "spec.environment", Detail: "already structured"}
+
+	got := rolloutBlockValidationError(original, []string{"shipper/b"})
+
+	if got != error(original) {
+		t.Errorf("expected the original *ValidationError to be returned unchanged")
+	}
+}
+
+func TestStatusForErrorBuildsOneCausePerBlockingRolloutBlock(t *testing.T) {
+	verr := &ValidationError{
+		Detail:                "blocked",
+		BlockingRolloutBlocks: []string{"shipper/a", "shipper/b"},
+	}
+
+	status := statusForError(verr)
+
+	if status.Reason != metav1.StatusReasonInvalid {
+		t.Errorf("Reason = %q, want %q", status.Reason, metav1.StatusReasonInvalid)
+	}
+	if status.Details == nil || len(status.Details.Causes) != 2 {
+		t.Fatalf("expected one cause per blocking rollout block, got %+v", status.Details)
+	}
+	for _, cause := range status.Details.Causes {
+		if cause.Field != rolloutBlockOverrideField {
+			t.Errorf("cause.Field = %q, want %q", cause.Field, rolloutBlockOverrideField)
+		}
+	}
+}
+
+func TestStatusForErrorFallsBackForPlainErrors(t *testing.T) {
+	status := statusForError(errors.New("boom"))
+
+	if status.Reason == metav1.StatusReasonInvalid {
+		t.Errorf("expected a plain error not to be reported as StatusReasonInvalid")
+	}
+	if status.Message != "boom" {
+		t.Errorf("Message = %q, want %q", status.Message, "boom")
+	}
+}
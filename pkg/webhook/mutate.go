@@ -0,0 +1,148 @@
+package webhook
+
+import (
+	"errors"
+
+	admission "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+)
+
+var errNotAnObject = errors.New("webhook: object does not implement metav1.Object")
+
+// Mutator mutates an admission object before it is persisted. The caller
+// hands over a copy it owns, so implementations may modify that object in
+// place and return it. Implementations must be safe to call concurrently.
+type Mutator interface {
+	Mutate(request *admission.AdmissionRequest, obj runtime.Object) (runtime.Object, error)
+}
+
+// MutatorFunc adapts a plain function to the Mutator interface.
+type MutatorFunc func(request *admission.AdmissionRequest, obj runtime.Object) (runtime.Object, error)
+
+func (f MutatorFunc) Mutate(request *admission.AdmissionRequest, obj runtime.Object) (runtime.Object, error) {
+	return f(request, obj)
+}
+
+// NewRolloutBlockOverrideMutator returns a Mutator that stamps the
+// shipper.RolloutBlocksOverrideAnnotation with defaultBlocks on objects
+// that don't already declare one. defaultBlocks is a configurable list of
+// "namespace/name" rollout block references.
+func NewRolloutBlockOverrideMutator(defaultBlocks []string) Mutator {
+	return MutatorFunc(func(request *admission.AdmissionRequest, obj runtime.Object) (runtime.Object, error) {
+		if len(defaultBlocks) == 0 {
+			return obj, nil
+		}
+
+		accessor, err := objectAnnotationsAccessor(obj)
+		if err != nil {
+			return obj, err
+		}
+
+		annotations := accessor.GetAnnotations()
+		if _, ok := annotations[shipper.RolloutBlocksOverrideAnnotation]; ok {
+			return obj, nil
+		}
+
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[shipper.RolloutBlocksOverrideAnnotation] = joinRolloutBlocks(defaultBlocks)
+		accessor.SetAnnotations(annotations)
+
+		return obj, nil
+	})
+}
+
+// userInfoTeamExtraKey is the UserInfo.Extra key shipper's authenticating
+// proxy sets to the requesting user's team, when known.
+const userInfoTeamExtraKey = "shipper.io/team"
+
+// NewOwnerLabelMutator returns a Mutator that stamps the shipper.io/team and
+// shipper.io/owner labels from the requesting user's UserInfo, when not
+// already set.
+func NewOwnerLabelMutator() Mutator {
+	return MutatorFunc(func(request *admission.AdmissionRequest, obj runtime.Object) (runtime.Object, error) {
+		accessor, err := objectAnnotationsAccessor(obj)
+		if err != nil {
+			return obj, err
+		}
+
+		labels := accessor.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+
+		if _, ok := labels["shipper.io/owner"]; !ok && request.UserInfo.Username != "" {
+			labels["shipper.io/owner"] = request.UserInfo.Username
+		}
+
+		if _, ok := labels["shipper.io/team"]; !ok {
+			if team := firstExtraValue(request.UserInfo.Extra, userInfoTeamExtraKey); team != "" {
+				labels["shipper.io/team"] = team
+			}
+		}
+
+		accessor.SetLabels(labels)
+
+		return obj, nil
+	})
+}
+
+// firstExtraValue returns the first value extra[key] carries, or "" if the
+// key is absent or empty. UserInfo.Extra models multi-valued attributes, but
+// a label can only hold one.
+func firstExtraValue(extra map[string]authenticationv1.ExtraValue, key string) string {
+	values, ok := extra[key]
+	if !ok || len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// NewApplicationDefaultsMutator returns a Mutator that fills in
+// Application.Spec.Template defaults (chart repo and values) when the
+// submitted object leaves them unset.
+func NewApplicationDefaultsMutator(defaultChartRepoURL string, defaultValues *shipper.ChartValues) Mutator {
+	return MutatorFunc(func(request *admission.AdmissionRequest, obj runtime.Object) (runtime.Object, error) {
+		application, ok := obj.(*shipper.Application)
+		if !ok {
+			return obj, nil
+		}
+
+		if application.Spec.Template.Chart.RepoURL == "" {
+			application.Spec.Template.Chart.RepoURL = defaultChartRepoURL
+		}
+
+		if application.Spec.Template.Values == nil {
+			application.Spec.Template.Values = defaultValues
+		}
+
+		return application, nil
+	})
+}
+
+// objectAnnotationsAccessor returns the metav1.Object view of obj so
+// mutators can read and write labels/annotations without a type switch per
+// Shipper kind.
+func objectAnnotationsAccessor(obj runtime.Object) (metav1.Object, error) {
+	accessor, ok := obj.(metav1.Object)
+	if !ok {
+		return nil, errNotAnObject
+	}
+	return accessor, nil
+}
+
+func joinRolloutBlocks(blocks []string) string {
+	result := ""
+	for i, block := range blocks {
+		if i > 0 {
+			result += ","
+		}
+		result += block
+	}
+	return result
+}
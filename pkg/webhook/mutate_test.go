@@ -0,0 +1,151 @@
+package webhook
+
+import (
+	"reflect"
+	"testing"
+
+	admission "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	shipper "github.com/bookingcom/shipper/pkg/apis/shipper/v1alpha1"
+)
+
+func TestRolloutBlockOverrideMutatorStampsDefaultBlocks(t *testing.T) {
+	mutator := NewRolloutBlockOverrideMutator([]string{"shipper/default-block"})
+
+	obj := &corev1.ConfigMap{}
+	mutated, err := mutator.Mutate(&admission.AdmissionRequest{}, obj)
+	if err != nil {
+		t.Fatalf("Mutate returned an error: %v", err)
+	}
+
+	cm := mutated.(*corev1.ConfigMap)
+	if got := cm.Annotations[shipper.RolloutBlocksOverrideAnnotation]; got != "shipper/default-block" {
+		t.Fatalf("expected the default block annotation to be stamped, got %q", got)
+	}
+}
+
+func TestRolloutBlockOverrideMutatorLeavesExistingAnnotationAlone(t *testing.T) {
+	mutator := NewRolloutBlockOverrideMutator([]string{"shipper/default-block"})
+
+	obj := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				shipper.RolloutBlocksOverrideAnnotation: "shipper/existing-block",
+			},
+		},
+	}
+
+	mutated, err := mutator.Mutate(&admission.AdmissionRequest{}, obj)
+	if err != nil {
+		t.Fatalf("Mutate returned an error: %v", err)
+	}
+
+	cm := mutated.(*corev1.ConfigMap)
+	if got := cm.Annotations[shipper.RolloutBlocksOverrideAnnotation]; got != "shipper/existing-block" {
+		t.Fatalf("expected the existing annotation to be left alone, got %q", got)
+	}
+}
+
+func TestOwnerLabelMutatorStampsOwnerAndTeam(t *testing.T) {
+	mutator := NewOwnerLabelMutator()
+
+	request := &admission.AdmissionRequest{
+		UserInfo: authenticationv1.UserInfo{
+			Username: "alice",
+			Extra: map[string]authenticationv1.ExtraValue{
+				userInfoTeamExtraKey: {"checkout"},
+			},
+		},
+	}
+
+	mutated, err := mutator.Mutate(request, &corev1.ConfigMap{})
+	if err != nil {
+		t.Fatalf("Mutate returned an error: %v", err)
+	}
+
+	cm := mutated.(*corev1.ConfigMap)
+	if got := cm.Labels["shipper.io/owner"]; got != "alice" {
+		t.Errorf("expected shipper.io/owner to be %q, got %q", "alice", got)
+	}
+	if got := cm.Labels["shipper.io/team"]; got != "checkout" {
+		t.Errorf("expected shipper.io/team to be %q, got %q", "checkout", got)
+	}
+}
+
+func TestOwnerLabelMutatorLeavesExistingLabelsAlone(t *testing.T) {
+	mutator := NewOwnerLabelMutator()
+
+	request := &admission.AdmissionRequest{
+		UserInfo: authenticationv1.UserInfo{Username: "alice"},
+	}
+
+	obj := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{"shipper.io/owner": "bob"},
+		},
+	}
+
+	mutated, err := mutator.Mutate(request, obj)
+	if err != nil {
+		t.Fatalf("Mutate returned an error: %v", err)
+	}
+
+	cm := mutated.(*corev1.ConfigMap)
+	if got := cm.Labels["shipper.io/owner"]; got != "bob" {
+		t.Errorf("expected the existing owner label to be left alone, got %q", got)
+	}
+}
+
+func TestApplicationDefaultsMutatorFillsUnsetFields(t *testing.T) {
+	defaultValues := &shipper.ChartValues{"replicaCount": 3}
+	mutator := NewApplicationDefaultsMutator("https://charts.example.com", defaultValues)
+
+	application := &shipper.Application{}
+	mutated, err := mutator.Mutate(&admission.AdmissionRequest{}, application)
+	if err != nil {
+		t.Fatalf("Mutate returned an error: %v", err)
+	}
+
+	got := mutated.(*shipper.Application)
+	if got.Spec.Template.Chart.RepoURL != "https://charts.example.com" {
+		t.Errorf("expected the default chart repo to be filled in, got %q", got.Spec.Template.Chart.RepoURL)
+	}
+	if !reflect.DeepEqual(got.Spec.Template.Values, defaultValues) {
+		t.Errorf("expected the default values to be filled in, got %v", got.Spec.Template.Values)
+	}
+}
+
+func TestApplicationDefaultsMutatorLeavesExistingValuesAlone(t *testing.T) {
+	existingValues := &shipper.ChartValues{"replicaCount": 5}
+	mutator := NewApplicationDefaultsMutator("https://charts.example.com", &shipper.ChartValues{"replicaCount": 3})
+
+	application := &shipper.Application{}
+	application.Spec.Template.Values = existingValues
+
+	mutated, err := mutator.Mutate(&admission.AdmissionRequest{}, application)
+	if err != nil {
+		t.Fatalf("Mutate returned an error: %v", err)
+	}
+
+	got := mutated.(*shipper.Application)
+	if !reflect.DeepEqual(got.Spec.Template.Values, existingValues) {
+		t.Errorf("expected the existing values to be left alone, got %v", got.Spec.Template.Values)
+	}
+}
+
+func TestApplicationDefaultsMutatorIgnoresOtherKinds(t *testing.T) {
+	mutator := NewApplicationDefaultsMutator("https://charts.example.com", nil)
+
+	obj := &corev1.ConfigMap{}
+	mutated, err := mutator.Mutate(&admission.AdmissionRequest{}, obj)
+	if err != nil {
+		t.Fatalf("Mutate returned an error: %v", err)
+	}
+
+	if mutated != obj {
+		t.Fatalf("expected a non-Application object to be returned unchanged")
+	}
+}
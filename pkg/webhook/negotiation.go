@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	admission "k8s.io/api/admission/v1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+)
+
+// convertAdmissionRequestToV1 copies a v1beta1 AdmissionRequest into its v1
+// shape. The two are structurally identical -- v1 was promoted unchanged --
+// so this is a plain field copy rather than a semantic conversion.
+func convertAdmissionRequestToV1(request *admissionv1beta1.AdmissionRequest) *admission.AdmissionRequest {
+	if request == nil {
+		return nil
+	}
+
+	return &admission.AdmissionRequest{
+		UID:                request.UID,
+		Kind:               request.Kind,
+		Resource:           request.Resource,
+		SubResource:        request.SubResource,
+		RequestKind:        request.RequestKind,
+		RequestResource:    request.RequestResource,
+		RequestSubResource: request.RequestSubResource,
+		Name:               request.Name,
+		Namespace:          request.Namespace,
+		Operation:          admission.Operation(request.Operation),
+		UserInfo:           request.UserInfo,
+		Object:             request.Object,
+		OldObject:          request.OldObject,
+		DryRun:             request.DryRun,
+		Options:            request.Options,
+	}
+}
+
+// convertAdmissionResponseToV1beta1 copies a v1 AdmissionResponse into its
+// v1beta1 shape, the inverse of convertAdmissionRequestToV1.
+func convertAdmissionResponseToV1beta1(response *admission.AdmissionResponse) *admissionv1beta1.AdmissionResponse {
+	if response == nil {
+		return nil
+	}
+
+	converted := &admissionv1beta1.AdmissionResponse{
+		UID:              response.UID,
+		Allowed:          response.Allowed,
+		Result:           response.Result,
+		Patch:            response.Patch,
+		AuditAnnotations: response.AuditAnnotations,
+	}
+
+	if response.PatchType != nil {
+		patchType := admissionv1beta1.PatchType(*response.PatchType)
+		converted.PatchType = &patchType
+	}
+
+	return converted
+}
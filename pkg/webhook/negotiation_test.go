@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	admission "k8s.io/api/admission/v1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+
+	"github.com/bookingcom/shipper/pkg/metrics/prometheus"
+)
+
+func TestAdaptHandlerRoundTripsBothAdmissionVersions(t *testing.T) {
+	c := &Webhook{webhookRequestMetric: prometheus.NewWebhookRequestMetrics()}
+	allowAll := func(*admission.AdmissionRequest) *admission.AdmissionResponse {
+		return &admission.AdmissionResponse{Allowed: true}
+	}
+	handler := c.adaptHandler(allowAll)
+
+	for _, apiVersion := range []string{"admission.k8s.io/v1", "admission.k8s.io/v1beta1"} {
+		t.Run(apiVersion, func(t *testing.T) {
+			body := `{
+				"apiVersion": "` + apiVersion + `",
+				"kind": "AdmissionReview",
+				"request": {"uid": "abc-123", "kind": {"kind": "Application"}}
+			}`
+
+			req := httptest.NewRequest(http.MethodPost, "/mutate", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			handler(rec, req)
+
+			var review struct {
+				metav1.TypeMeta
+				Response struct {
+					UID     apitypes.UID
+					Allowed bool
+				}
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &review); err != nil {
+				t.Fatalf("response wasn't valid JSON: %v (body: %s)", err, rec.Body.String())
+			}
+
+			if review.APIVersion != apiVersion {
+				t.Errorf("response apiVersion = %q, want %q", review.APIVersion, apiVersion)
+			}
+			if review.Response.UID != "abc-123" {
+				t.Errorf("response UID = %q, want %q", review.Response.UID, "abc-123")
+			}
+			if !review.Response.Allowed {
+				t.Errorf("expected the response to be allowed")
+			}
+		})
+	}
+}
+
+func TestConvertAdmissionRequestToV1CopiesFields(t *testing.T) {
+	request := &admissionv1beta1.AdmissionRequest{
+		UID:       apitypes.UID("abc-123"),
+		Name:      "my-release",
+		Namespace: "shipper-system",
+		Operation: admissionv1beta1.Create,
+	}
+
+	converted := convertAdmissionRequestToV1(request)
+
+	if converted.UID != request.UID {
+		t.Errorf("UID = %q, want %q", converted.UID, request.UID)
+	}
+	if converted.Name != request.Name {
+		t.Errorf("Name = %q, want %q", converted.Name, request.Name)
+	}
+	if converted.Operation != admission.Create {
+		t.Errorf("Operation = %q, want %q", converted.Operation, admission.Create)
+	}
+}
+
+func TestConvertAdmissionRequestToV1NilIsNil(t *testing.T) {
+	if got := convertAdmissionRequestToV1(nil); got != nil {
+		t.Errorf("expected nil, got %+v", got)
+	}
+}
+
+func TestConvertAdmissionResponseToV1beta1CopiesPatchType(t *testing.T) {
+	patchType := admission.PatchTypeJSONPatch
+	response := &admission.AdmissionResponse{
+		UID:       apitypes.UID("abc-123"),
+		Allowed:   true,
+		Patch:     []byte(`[{"op":"add","path":"/x","value":1}]`),
+		PatchType: &patchType,
+	}
+
+	converted := convertAdmissionResponseToV1beta1(response)
+
+	if converted.UID != response.UID {
+		t.Errorf("UID = %q, want %q", converted.UID, response.UID)
+	}
+	if converted.PatchType == nil || *converted.PatchType != admissionv1beta1.PatchTypeJSONPatch {
+		t.Errorf("PatchType = %v, want %q", converted.PatchType, admissionv1beta1.PatchTypeJSONPatch)
+	}
+}
+
+func TestConvertAdmissionResponseToV1beta1NilIsNil(t *testing.T) {
+	if got := convertAdmissionResponseToV1beta1(nil); got != nil {
+		t.Errorf("expected nil, got %+v", got)
+	}
+}
@@ -0,0 +1,31 @@
+package webhook
+
+import (
+	"encoding/json"
+
+	jsonpatch "github.com/mattbaird/jsonpatch"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// createJSONPatch diffs the marshaled form of original and mutated and
+// returns the result as a JSON Patch document (RFC 6902), the same approach
+// most admission controllers use to turn an in-memory mutation into a patch
+// the apiserver can apply.
+func createJSONPatch(original, mutated runtime.Object) ([]byte, error) {
+	originalJSON, err := json.Marshal(original)
+	if err != nil {
+		return nil, err
+	}
+
+	mutatedJSON, err := json.Marshal(mutated)
+	if err != nil {
+		return nil, err
+	}
+
+	operations, err := jsonpatch.CreatePatch(originalJSON, mutatedJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(operations)
+}
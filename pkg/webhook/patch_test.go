@@ -0,0 +1,47 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCreateJSONPatchReflectsLabelChange(t *testing.T) {
+	original := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm"},
+	}
+	mutated := original.DeepCopy()
+	mutated.Labels = map[string]string{"shipper.io/owner": "alice"}
+
+	patch, err := createJSONPatch(original, mutated)
+	if err != nil {
+		t.Fatalf("createJSONPatch returned an error: %v", err)
+	}
+
+	var operations []map[string]interface{}
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		t.Fatalf("patch wasn't valid JSON: %v", err)
+	}
+
+	if len(operations) == 0 {
+		t.Fatalf("expected at least one JSON Patch operation for the label change")
+	}
+}
+
+func TestCreateJSONPatchIsEmptyWhenNothingChanged(t *testing.T) {
+	original := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm"},
+	}
+	mutated := original.DeepCopy()
+
+	patch, err := createJSONPatch(original, mutated)
+	if err != nil {
+		t.Fatalf("createJSONPatch returned an error: %v", err)
+	}
+
+	if string(patch) != "[]" {
+		t.Fatalf("expected an empty patch, got %s", patch)
+	}
+}
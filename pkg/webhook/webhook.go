@@ -12,11 +12,14 @@ import (
 	"reflect"
 	"time"
 
-	admission "k8s.io/api/admission/v1beta1"
-	kubeclient "k8s.io/api/admission/v1beta1"
+	admission "k8s.io/api/admission/v1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog"
 
@@ -37,14 +40,24 @@ type Webhook struct {
 	rolloutBlocksLister listers.RolloutBlockLister
 	rolloutBlocksSynced cache.InformerSynced
 
+	applicationsLister listers.ApplicationLister
+	applicationsSynced cache.InformerSynced
+
+	releasesLister listers.ReleaseLister
+	releasesSynced cache.InformerSynced
+
 	bindAddr string
 	bindPort string
 
 	tlsCertFile       string
 	tlsPrivateKeyFile string
 
-	webhookHealthMetric prometheus.WebhookMetric
-	heartbeatPeriod     time.Duration
+	webhookHealthMetric  prometheus.WebhookMetric
+	webhookRequestMetric prometheus.WebhookRequestMetrics
+	heartbeatPeriod      time.Duration
+
+	mutators          map[string]Mutator
+	mutatorPrototypes map[string]func() runtime.Object
 }
 
 var (
@@ -53,31 +66,65 @@ var (
 	deserializer  = codecs.UniversalDeserializer()
 )
 
+func init() {
+	// Register both versions so adaptHandler can decode an AdmissionReview
+	// regardless of which one the apiserver sent: admission.k8s.io/v1beta1
+	// clusters are still out there, and v1 is mandatory from Kubernetes 1.22
+	// onwards.
+	utilruntime.Must(admission.AddToScheme(runtimeScheme))
+	utilruntime.Must(admissionv1beta1.AddToScheme(runtimeScheme))
+}
+
 func NewWebhook(
 	bindAddr, bindPort, tlsPrivateKeyFile, tlsCertFile string,
 	shipperClientset clientset.Interface,
 	shipperInformerFactory informers.SharedInformerFactory,
 	webhookMetric prometheus.WebhookMetric,
+	webhookRequestMetric prometheus.WebhookRequestMetrics,
 	heartbeatPeriod time.Duration,
 ) *Webhook {
 	rolloutBlocksInformer := shipperInformerFactory.Shipper().V1alpha1().RolloutBlocks()
+	applicationsInformer := shipperInformerFactory.Shipper().V1alpha1().Applications()
+	releasesInformer := shipperInformerFactory.Shipper().V1alpha1().Releases()
 
 	return &Webhook{
 		shipperClientset:    shipperClientset,
 		rolloutBlocksLister: rolloutBlocksInformer.Lister(),
 		rolloutBlocksSynced: rolloutBlocksInformer.Informer().HasSynced,
 
+		applicationsLister: applicationsInformer.Lister(),
+		applicationsSynced: applicationsInformer.Informer().HasSynced,
+
+		releasesLister: releasesInformer.Lister(),
+		releasesSynced: releasesInformer.Informer().HasSynced,
+
 		bindAddr: bindAddr,
 		bindPort: bindPort,
 
 		tlsPrivateKeyFile: tlsPrivateKeyFile,
 		tlsCertFile:       tlsCertFile,
 
-		webhookHealthMetric: webhookMetric,
-		heartbeatPeriod:     heartbeatPeriod,
+		webhookHealthMetric:  webhookMetric,
+		webhookRequestMetric: webhookRequestMetric,
+		heartbeatPeriod:      heartbeatPeriod,
+
+		mutators:          make(map[string]Mutator),
+		mutatorPrototypes: make(map[string]func() runtime.Object),
 	}
 }
 
+// RegisterMutator wires a Mutator into the webhook for the given resource
+// kind (e.g. "Application"). prototype returns a fresh zero-value object of
+// that kind, which mutateHandlerFunc uses to decode the submitted object --
+// without it, a mutator registered for a kind mutateHandlerFunc doesn't
+// already know how to decode would silently never run. Registering a
+// mutator for a kind that already has one replaces it; this is mainly
+// useful for tests.
+func (c *Webhook) RegisterMutator(kind string, mutator Mutator, prototype func() runtime.Object) {
+	c.mutators[kind] = mutator
+	c.mutatorPrototypes[kind] = prototype
+}
+
 func (c *Webhook) Run(stopCh <-chan struct{}) {
 	addr := c.bindAddr + ":" + c.bindPort
 	mux := c.initializeHandlers()
@@ -86,7 +133,7 @@ func (c *Webhook) Run(stopCh <-chan struct{}) {
 		Handler: mux,
 	}
 
-	if !cache.WaitForCacheSync(stopCh, c.rolloutBlocksSynced) {
+	if !cache.WaitForCacheSync(stopCh, c.rolloutBlocksSynced, c.applicationsSynced, c.releasesSynced) {
 		klog.Fatalf("failed to wait for caches to sync")
 		return
 	}
@@ -99,9 +146,17 @@ func (c *Webhook) Run(stopCh <-chan struct{}) {
 		if c.tlsCertFile == "" || c.tlsPrivateKeyFile == "" {
 			serverError = server.ListenAndServe()
 		} else {
-			c.observeCertificateExpiration(addr)
+			reloader, err := NewCertReloader(c.tlsCertFile, c.tlsPrivateKeyFile, func(cert *x509.Certificate) {
+				c.webhookHealthMetric.ObserveCertificateExpiration(addr, cert.NotAfter)
+			})
+			if err != nil {
+				klog.Fatalf("failed to start shipper-webhook TLS certificate watcher: %v", err)
+				return
+			}
 
-			serverError = server.ListenAndServeTLS(c.tlsCertFile, c.tlsPrivateKeyFile)
+			server.TLSConfig = &tls.Config{GetCertificate: reloader.GetCertificate}
+
+			serverError = server.ListenAndServeTLS("", "")
 		}
 
 		if serverError != nil && serverError != http.ErrServerClosed {
@@ -121,22 +176,6 @@ func (c *Webhook) Run(stopCh <-chan struct{}) {
 	}
 }
 
-func (c *Webhook) observeCertificateExpiration(addr string) {
-	cert, err := tls.LoadX509KeyPair(c.tlsCertFile, c.tlsPrivateKeyFile)
-	if err != nil {
-		klog.Errorf("fail to load TLS certificate from file with private key %v", err)
-		return
-	}
-	certificate, err := x509.ParseCertificate(cert.Certificate[0])
-	if err != nil {
-		klog.Errorf("fail to parse TLS certificate %v", err)
-		return
-	}
-	expiryTime := certificate.NotAfter
-	c.webhookHealthMetric.ObserveCertificateExpiration(addr, expiryTime)
-	klog.V(8).Infof("Shipper Validating Webhooks TLS certificate expires on %v", certificate.NotAfter)
-}
-
 func (c *Webhook) startHeartbeatRoutine(ctx context.Context, host string) {
 	ticker := time.NewTicker(c.heartbeatPeriod)
 	go func() {
@@ -154,12 +193,19 @@ func (c *Webhook) startHeartbeatRoutine(ctx context.Context, host string) {
 
 func (c *Webhook) initializeHandlers() *http.ServeMux {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/validate", adaptHandler(c.validateHandlerFunc))
+	mux.HandleFunc("/validate", c.adaptHandler(c.validateHandlerFunc))
+	mux.HandleFunc("/mutate", c.adaptHandler(c.mutateHandlerFunc))
 	return mux
 }
 
-// adaptHandler wraps an admission review function to be consumed through HTTP.
-func adaptHandler(handler func(*admission.AdmissionReview) *admission.AdmissionResponse) func(http.ResponseWriter, *http.Request) {
+// adaptHandler wraps an admission review function to be consumed through
+// HTTP. The handler itself only ever sees the v1 admission types; adaptHandler
+// decodes whichever version the apiserver sent (v1 or the deprecated
+// v1beta1) and encodes the response back in that same version, so
+// validateHandlerFunc/mutateHandlerFunc stay version-agnostic. It also owns
+// the per-request Prometheus instrumentation, since that's the one place
+// every admission request passes through regardless of kind or operation.
+func (c *Webhook) adaptHandler(handler func(*admission.AdmissionRequest) *admission.AdmissionResponse) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var body []byte
 		if r.Body != nil {
@@ -175,50 +221,120 @@ func adaptHandler(handler func(*admission.AdmissionReview) *admission.AdmissionR
 
 		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
 		if err != nil {
+			c.webhookRequestMetric.ObserveDecodeError(r.Header.Get("Content-Type"))
 			http.Error(w, "Invalid content-type", http.StatusUnsupportedMediaType)
 			return
 		}
 
 		if mediaType != "application/json" {
+			c.webhookRequestMetric.ObserveDecodeError(mediaType)
 			http.Error(w, "invalid Content-Type, expect `application/json`", http.StatusUnsupportedMediaType)
 			return
 		}
 
-		var admissionResponse *admission.AdmissionResponse
-		ar := admission.AdmissionReview{}
-		if _, _, err := deserializer.Decode(body, nil, &ar); err != nil {
-			admissionResponse = &admission.AdmissionResponse{
-				Result: &metav1.Status{
-					Message: err.Error(),
-				},
-			}
-		} else {
-			admissionResponse = handler(&ar)
+		obj, gvk, err := deserializer.Decode(body, nil, nil)
+		if err != nil {
+			c.webhookRequestMetric.ObserveDecodeError(mediaType)
+			writeAdmissionResponse(w, admission.SchemeGroupVersion.WithKind("AdmissionReview"),
+				&admission.AdmissionResponse{
+					Result: &metav1.Status{Message: err.Error()},
+				})
+			return
 		}
 
-		admissionReview := admission.AdmissionReview{}
-		if admissionResponse != nil {
-			admissionReview.Response = admissionResponse
-			if ar.Request != nil {
-				admissionReview.Response.UID = ar.Request.UID
+		var requestUID apitypes.UID
+		var request *admission.AdmissionRequest
+		var reviewGVK schema.GroupVersionKind
+
+		switch review := obj.(type) {
+		case *admission.AdmissionReview:
+			reviewGVK = *gvk
+			if review.Request != nil {
+				requestUID = review.Request.UID
+				request = review.Request
 			}
+		case *admissionv1beta1.AdmissionReview:
+			reviewGVK = *gvk
+			if review.Request != nil {
+				requestUID = review.Request.UID
+				request = convertAdmissionRequestToV1(review.Request)
+			}
+		default:
+			c.webhookRequestMetric.ObserveDecodeError(mediaType)
+			http.Error(w, fmt.Sprintf("unsupported admission review kind %v", gvk), http.StatusBadRequest)
+			return
 		}
 
-		resp, err := json.Marshal(admissionReview)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("could not encode response: %v", err), http.StatusInternalServerError)
+		if request == nil {
 			return
 		}
 
-		if _, err := w.Write(resp); err != nil {
-			http.Error(w, fmt.Sprintf("could not write response: %v", err), http.StatusInternalServerError)
-			return
+		resourceKind := request.Kind.Kind
+		operation := string(request.Operation)
+
+		c.webhookRequestMetric.IncInFlight(resourceKind)
+		start := time.Now()
+
+		admissionResponse := handler(request)
+
+		c.webhookRequestMetric.DecInFlight(resourceKind)
+		c.webhookRequestMetric.ObserveRequestLatency(resourceKind, operation, admissionResponse.Allowed, time.Since(start))
+		c.webhookRequestMetric.ObserveRequestTotal(resourceKind, operation, admissionResponse.Allowed, admissionReason(admissionResponse))
+
+		admissionResponse.UID = requestUID
+
+		writeAdmissionResponse(w, reviewGVK, admissionResponse)
+	}
+}
+
+// admissionReason extracts a low-cardinality reason label from an admission
+// response for the decisions counter: the structured Reason when one was
+// set, "Allowed" when the request was let through, or "Denied" otherwise.
+func admissionReason(response *admission.AdmissionResponse) string {
+	if response.Result != nil && response.Result.Reason != "" {
+		return string(response.Result.Reason)
+	}
+	if response.Allowed {
+		return "Allowed"
+	}
+	return "Denied"
+}
+
+// writeAdmissionResponse encodes admissionResponse as an AdmissionReview in
+// the same version the request came in (reviewGVK), so e.g. a v1beta1
+// request always gets a v1beta1 response back.
+func writeAdmissionResponse(w http.ResponseWriter, reviewGVK schema.GroupVersionKind, admissionResponse *admission.AdmissionResponse) {
+	var reviewObj runtime.Object
+
+	if reviewGVK.GroupVersion() == admissionv1beta1.SchemeGroupVersion {
+		reviewObj = &admissionv1beta1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: admissionv1beta1.SchemeGroupVersion.String(), Kind: "AdmissionReview"},
+			Response: convertAdmissionResponseToV1beta1(admissionResponse),
+		}
+	} else {
+		reviewObj = &admission.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: admission.SchemeGroupVersion.String(), Kind: "AdmissionReview"},
+			Response: admissionResponse,
 		}
 	}
+
+	resp, err := json.Marshal(reviewObj)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not encode response: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := w.Write(resp); err != nil {
+		http.Error(w, fmt.Sprintf("could not write response: %v", err), http.StatusInternalServerError)
+		return
+	}
 }
 
-func (c *Webhook) validateHandlerFunc(review *admission.AdmissionReview) *admission.AdmissionResponse {
-	request := review.Request
+func (c *Webhook) validateHandlerFunc(request *admission.AdmissionRequest) *admission.AdmissionResponse {
+	if request.Operation == admission.Delete {
+		return c.validateDeleteHandlerFunc(request)
+	}
+
 	var err error
 
 	switch request.Kind.Kind {
@@ -251,6 +367,44 @@ func (c *Webhook) validateHandlerFunc(review *admission.AdmissionReview) *admiss
 		err = json.Unmarshal(request.Object.Raw, &rolloutBlock)
 	}
 
+	if err != nil {
+		return &admission.AdmissionResponse{
+			Result: statusForError(err),
+		}
+	}
+
+	return &admission.AdmissionResponse{
+		Allowed: true,
+	}
+}
+
+// mutateHandlerFunc looks up a registered Mutator for the request's kind,
+// applies it to a deep copy of the submitted object, and returns the diff
+// between the original and mutated object as a JSON Patch. Kinds with no
+// registered mutator are allowed unchanged.
+func (c *Webhook) mutateHandlerFunc(request *admission.AdmissionRequest) *admission.AdmissionResponse {
+	mutator, ok := c.mutators[request.Kind.Kind]
+	if !ok {
+		return &admission.AdmissionResponse{Allowed: true}
+	}
+
+	// RegisterMutator requires a prototype alongside the Mutator, so this
+	// can never miss for a kind that's actually registered.
+	newPrototype, ok := c.mutatorPrototypes[request.Kind.Kind]
+	if !ok {
+		return &admission.AdmissionResponse{Allowed: true}
+	}
+	original := newPrototype()
+
+	if err := json.Unmarshal(request.Object.Raw, original); err != nil {
+		return &admission.AdmissionResponse{
+			Result: &metav1.Status{
+				Message: err.Error(),
+			},
+		}
+	}
+
+	mutated, err := mutator.Mutate(request, original.DeepCopyObject())
 	if err != nil {
 		return &admission.AdmissionResponse{
 			Result: &metav1.Status{
@@ -259,8 +413,24 @@ func (c *Webhook) validateHandlerFunc(review *admission.AdmissionReview) *admiss
 		}
 	}
 
+	patch, err := createJSONPatch(original, mutated)
+	if err != nil {
+		return &admission.AdmissionResponse{
+			Result: &metav1.Status{
+				Message: err.Error(),
+			},
+		}
+	}
+
+	if len(patch) == 0 || string(patch) == "[]" {
+		return &admission.AdmissionResponse{Allowed: true}
+	}
+
+	patchType := admission.PatchTypeJSONPatch
 	return &admission.AdmissionResponse{
-		Allowed: true,
+		Allowed:   true,
+		Patch:     patch,
+		PatchType: &patchType,
 	}
 }
 
@@ -270,13 +440,16 @@ func (c *Webhook) validateRelease(request *admission.AdmissionRequest, release s
 	if err != nil {
 		return err
 	}
+	overrideNames, existingBlockNames := overrides.Keys(), existingBlocks.Keys()
 	if err = rolloutblock.ValidateAnnotations(existingBlocks, overrides); err != nil {
-		return err
+		return rolloutBlockValidationError(err, invalidRolloutBlockOverrides(existingBlockNames, overrideNames))
 	}
 	switch request.Operation {
-	case kubeclient.Create:
-		err = rolloutblock.ValidateBlocks(existingBlocks, overrides)
-	case kubeclient.Update:
+	case admission.Create:
+		if err = rolloutblock.ValidateBlocks(existingBlocks, overrides); err != nil {
+			return rolloutBlockValidationError(err, rolloutBlocksMissingOverride(existingBlockNames, overrideNames))
+		}
+	case admission.Update:
 		var oldRelease shipper.Release
 		err = json.Unmarshal(request.OldObject.Raw, &oldRelease)
 		if err != nil {
@@ -285,16 +458,21 @@ func (c *Webhook) validateRelease(request *admission.AdmissionRequest, release s
 
 		// validate against rollout blocks
 		if !reflect.DeepEqual(release.Spec, oldRelease.Spec) {
-			err = rolloutblock.ValidateBlocks(existingBlocks, overrides)
+			if err = rolloutblock.ValidateBlocks(existingBlocks, overrides); err != nil {
+				return rolloutBlockValidationError(err, rolloutBlocksMissingOverride(existingBlockNames, overrideNames))
+			}
 		}
 
 		// make sure the environment wasn't changed
 		if !reflect.DeepEqual(release.Spec.Environment, oldRelease.Spec.Environment) {
-			return fmt.Errorf("the Release environment must not be changed; consider editing the Application object")
+			return &ValidationError{
+				Field:  "spec.environment",
+				Detail: "the Release environment must not be changed; consider editing the Application object",
+			}
 		}
 	}
 
-	return err
+	return nil
 }
 
 func (c *Webhook) validateApplication(request *admission.AdmissionRequest, application shipper.Application) error {
@@ -303,13 +481,16 @@ func (c *Webhook) validateApplication(request *admission.AdmissionRequest, appli
 	if err != nil {
 		return err
 	}
+	overrideNames, existingBlockNames := overrides.Keys(), existingBlocks.Keys()
 	if err = rolloutblock.ValidateAnnotations(existingBlocks, overrides); err != nil {
-		return err
+		return rolloutBlockValidationError(err, invalidRolloutBlockOverrides(existingBlockNames, overrideNames))
 	}
 	switch request.Operation {
-	case kubeclient.Create:
-		err = rolloutblock.ValidateBlocks(existingBlocks, overrides)
-	case kubeclient.Update:
+	case admission.Create:
+		if err = rolloutblock.ValidateBlocks(existingBlocks, overrides); err != nil {
+			return rolloutBlockValidationError(err, rolloutBlocksMissingOverride(existingBlockNames, overrideNames))
+		}
+	case admission.Update:
 		var oldApp shipper.Application
 		err = json.Unmarshal(request.OldObject.Raw, &oldApp)
 		if err != nil {
@@ -317,9 +498,11 @@ func (c *Webhook) validateApplication(request *admission.AdmissionRequest, appli
 		}
 
 		if !reflect.DeepEqual(application.Spec, oldApp.Spec) {
-			err = rolloutblock.ValidateBlocks(existingBlocks, overrides)
+			if err = rolloutblock.ValidateBlocks(existingBlocks, overrides); err != nil {
+				return rolloutBlockValidationError(err, rolloutBlocksMissingOverride(existingBlockNames, overrideNames))
+			}
 		}
 	}
 
-	return err
+	return nil
 }